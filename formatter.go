@@ -5,11 +5,15 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
+	"github.com/albenik/logrus-text-formatter/optag"
 	"github.com/mgutz/ansi"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/ssh/terminal"
@@ -26,6 +30,7 @@ type ColorScheme struct {
 	Panic  string
 	Prefix string
 	Func   string
+	Trace  string
 }
 
 type colorFunc func(string) string
@@ -39,12 +44,24 @@ type compiledColorScheme struct {
 	Panic  colorFunc
 	Prefix colorFunc
 	Func   colorFunc
+	Trace  colorFunc
 }
 
 type Instance struct {
 	// Use colors if TTY detected
 	UseColors bool
 
+	// Force colored output even when the output isn't a TTY
+	ForceColors bool
+
+	// Never colorize output
+	DisableColors bool
+
+	// Decide on colorizing by inspecting NO_COLOR, CLICOLOR and CLICOLOR_FORCE
+	// environment variables and checking whether the logger's output is a TTY.
+	// Takes precedence over UseColors/ForceColors/DisableColors.
+	EnvironmentOverrideColors bool
+
 	// Disable timestamp logging. useful when output is redirected to logging
 	// system that already adds timestamps.
 	DisableTimestamp bool
@@ -63,6 +80,36 @@ type Instance struct {
 	PrefixFieldWidth int
 	FuncFieldName    string
 
+	// FieldsOrder pins the listed keys to the front of the field tail, in the
+	// given order. Any remaining fields are appended afterwards, sorted
+	// alphabetically as before.
+	FieldsOrder []string
+
+	// TraceFieldName names the reserved field holding span/trace context
+	// (see the optag package). Defaults to "__t".
+	TraceFieldName string
+
+	// DisableCaller suppresses caller rendering even when the entry carries
+	// one (i.e. logrus.SetReportCaller(true) is in effect).
+	DisableCaller bool
+
+	// CallerFirst prints the caller right after the level (and after the
+	// trace column, when one is rendered) instead of appending it to the
+	// field tail.
+	CallerFirst bool
+
+	// CustomCallerFormatter overrides the default "file:line#func" rendering.
+	CustomCallerFormatter func(*runtime.Frame) string
+
+	// QuoteEmptyFields wraps empty field values in quotes.
+	QuoteEmptyFields bool
+
+	// ForceQuote always wraps field values in quotes, regardless of content.
+	ForceQuote bool
+
+	// DisableQuote never wraps field values in quotes, regardless of content.
+	DisableQuote bool
+
 	colorScheme *compiledColorScheme
 
 	sync.Once
@@ -83,6 +130,7 @@ var (
 		Panic:  "red+h",
 		Prefix: "cyan",
 		Func:   "white",
+		Trace:  "magenta",
 	}
 	noColors *compiledColorScheme = &compiledColorScheme{
 		Debug:  nocolor,
@@ -93,6 +141,7 @@ var (
 		Panic:  nocolor,
 		Prefix: nocolor,
 		Func:   nocolor,
+		Trace:  nocolor,
 	}
 	defaultCompiledColorScheme *compiledColorScheme = compileColorScheme(defaultColors)
 )
@@ -101,6 +150,14 @@ func miniTS() float64 {
 	return time.Since(baseTimestamp).Seconds()
 }
 
+// bufferPool recycles the *bytes.Buffer used when entry.Buffer is nil, so
+// repeated Format calls don't allocate a fresh buffer every time.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
 func getCompiledColor(main string, fallback string) colorFunc {
 	var style string
 	if main != "" {
@@ -121,6 +178,7 @@ func compileColorScheme(s *ColorScheme) *compiledColorScheme {
 		Debug:  getCompiledColor(s.Debug, defaultColors.Debug),
 		Prefix: getCompiledColor(s.Prefix, defaultColors.Prefix),
 		Func:   getCompiledColor(s.Func, defaultColors.Func),
+		Trace:  getCompiledColor(s.Trace, defaultColors.Trace),
 	}
 }
 
@@ -133,36 +191,180 @@ func (f *Instance) checkIfTerminal(w io.Writer) bool {
 	}
 }
 
+// isTruthyEnv reports whether the named environment variable is set to a
+// non-empty value other than "0", following the "bixense" CLICOLOR_FORCE
+// convention.
+func isTruthyEnv(name string) bool {
+	v := os.Getenv(name)
+	return v != "" && v != "0"
+}
+
+func (f *Instance) isColored(entry *logrus.Entry) bool {
+	if f.EnvironmentOverrideColors {
+		switch {
+		case isTruthyEnv("CLICOLOR_FORCE"):
+			return true
+		case os.Getenv("NO_COLOR") != "" || os.Getenv("CLICOLOR") == "0":
+			return false
+		default:
+			var out io.Writer
+			if entry.Logger != nil {
+				out = entry.Logger.Out
+			}
+			return f.checkIfTerminal(out)
+		}
+	}
+
+	if f.ForceColors {
+		return true
+	}
+	if f.DisableColors {
+		return false
+	}
+	return f.UseColors
+}
+
 func (f *Instance) SetColorScheme(colorScheme *ColorScheme) {
 	f.colorScheme = compileColorScheme(colorScheme)
 }
 
-func (f *Instance) Format(entry *logrus.Entry) ([]byte, error) {
-	// init
-	f.Once.Do(func() {
-		if len(f.PrefixFieldName) == 0 {
-			f.PrefixFieldName = "__p"
-		}
-		if len(f.FuncFieldName) == 0 {
-			f.FuncFieldName = "__f"
-		}
-		if len(f.TimestampFormat) == 0 {
-			f.TimestampFormat = defaultTimestampFormat
+func (f *Instance) formatCaller(frame *runtime.Frame) string {
+	if f.CustomCallerFormatter != nil {
+		return f.CustomCallerFormatter(frame)
+	}
+	b := make([]byte, 0, len(frame.File)+len(frame.Function)+12)
+	b = append(b, frame.File...)
+	b = append(b, ':')
+	b = strconv.AppendInt(b, int64(frame.Line), 10)
+	b = append(b, '#')
+	b = append(b, frame.Function...)
+	return string(b)
+}
+
+func (f *Instance) applyDefaults(entry *logrus.Entry) {
+	if len(f.PrefixFieldName) == 0 {
+		f.PrefixFieldName = "__p"
+	}
+	if len(f.FuncFieldName) == 0 {
+		f.FuncFieldName = "__f"
+	}
+	if len(f.TraceFieldName) == 0 {
+		f.TraceFieldName = "__t"
+	}
+	if len(f.TimestampFormat) == 0 {
+		f.TimestampFormat = defaultTimestampFormat
+	}
+	if f.colorScheme == nil {
+		if f.isColored(entry) {
+			f.colorScheme = defaultCompiledColorScheme
+		} else {
+			f.colorScheme = noColors
 		}
-		if f.colorScheme == nil {
-			if f.UseColors {
-				f.colorScheme = defaultCompiledColorScheme
-			} else {
-				f.colorScheme = noColors
+	}
+}
+
+// field is a single key/value pair destined for a structured (logfmt/JSON)
+// output record, in render order.
+type field struct {
+	Key   string
+	Value interface{}
+}
+
+// buildRecord assembles the ordered list of fields a structured formatter
+// should render for entry, surfacing the reserved __t/__p/__f fields under
+// traceKey/prefixKey/funcKey instead of interpolating them into the message.
+func (f *Instance) buildRecord(entry *logrus.Entry, traceKey, prefixKey, funcKey string) []field {
+	f.Once.Do(func() { f.applyDefaults(entry) })
+
+	levelText := entry.Level.String()
+	if entry.Level == logrus.WarnLevel {
+		levelText = "warn"
+	}
+	if !f.LowercaseLevels {
+		levelText = strings.ToUpper(levelText)
+	}
+
+	rec := make([]field, 0, len(entry.Data)+4)
+	if !f.DisableTimestamp {
+		rec = append(rec, field{"time", entry.Time.Format(f.TimestampFormat)})
+	}
+	rec = append(rec, field{"level", levelText})
+
+	if v, ok := entry.Data[f.TraceFieldName]; ok {
+		rec = append(rec, field{traceKey, v})
+	}
+	if v, ok := entry.Data[f.PrefixFieldName]; ok {
+		rec = append(rec, field{prefixKey, v})
+	}
+	if v, ok := entry.Data[f.FuncFieldName]; ok {
+		rec = append(rec, field{funcKey, v})
+	}
+
+	rec = append(rec, field{"msg", entry.Message})
+
+	if v, ok := entry.Data[logrus.ErrorKey]; ok {
+		rec = append(rec, field{logrus.ErrorKey, v})
+	}
+
+	// Keys that already occupy a slot in rec above. An ordinary entry.Data
+	// field sharing one of these names would otherwise clobber the
+	// operational field of the same name once a structured formatter
+	// flattens rec into a single-namespace map.
+	occupied := map[string]struct{}{
+		"time":          {},
+		"level":         {},
+		"msg":           {},
+		traceKey:        {},
+		prefixKey:       {},
+		funcKey:         {},
+		logrus.ErrorKey: {},
+	}
+
+	remaining := make(map[string]struct{}, len(entry.Data))
+	for k := range entry.Data {
+		switch k {
+		case f.PrefixFieldName, f.FuncFieldName, f.TraceFieldName, logrus.ErrorKey:
+			continue
+		default:
+			if _, taken := occupied[k]; taken {
+				continue
 			}
+			remaining[k] = struct{}{}
 		}
-	})
+	}
+
+	keys := make([]string, 0, len(remaining))
+	for _, k := range f.FieldsOrder {
+		if _, ok := remaining[k]; ok {
+			keys = append(keys, k)
+			delete(remaining, k)
+		}
+	}
+	rest := make([]string, 0, len(remaining))
+	for k := range remaining {
+		rest = append(rest, k)
+	}
+	sort.Strings(rest)
+	keys = append(keys, rest...)
+
+	for _, k := range keys {
+		rec = append(rec, field{k, entry.Data[k]})
+	}
+
+	return rec
+}
+
+func (f *Instance) Format(entry *logrus.Entry) ([]byte, error) {
+	f.Once.Do(func() { f.applyDefaults(entry) })
 
 	var buf *bytes.Buffer
+	var pooled bool
 	if entry.Buffer != nil {
 		buf = entry.Buffer
 	} else {
-		buf = &bytes.Buffer{}
+		buf = bufferPool.Get().(*bytes.Buffer)
+		buf.Reset()
+		pooled = true
 	}
 
 	var levelColor colorFunc
@@ -195,82 +397,192 @@ func (f *Instance) Format(entry *logrus.Entry) ([]byte, error) {
 	if !f.DisableTimestamp {
 		var ts string
 		if !f.FullTimestamp {
-			ts = fmt.Sprintf("[%f]", miniTS())
+			ts = "[" + strconv.FormatFloat(miniTS(), 'f', 6, 64) + "]"
 		} else {
-			ts = entry.Time.Format(f.TimestampFormat)
+			var tsBuf [64]byte
+			ts = string(entry.Time.AppendFormat(tsBuf[:0], f.TimestampFormat))
+		}
+		buf.WriteString(levelColor(ts))
+		buf.WriteByte(' ')
+	}
+
+	levelPadded := levelText
+	if len(levelText) < 5 {
+		levelPadded = strings.Repeat(" ", 5-len(levelText)) + levelText
+	}
+	buf.WriteString(levelColor(levelPadded))
+
+	// Trace
+	var traceIsTag bool
+	if v, ok := entry.Data[f.TraceFieldName]; ok {
+		if tag, ok := v.(optag.Tag); ok {
+			traceIsTag = true
+			buf.WriteByte(' ')
+			buf.WriteString(f.colorScheme.Trace(tag.String()))
 		}
-		fmt.Fprint(buf, levelColor(ts), " ")
 	}
 
-	fmt.Fprint(buf, levelColor(fmt.Sprintf("%5s", levelText)))
+	hasCaller := !f.DisableCaller && entry.HasCaller()
+	var callerStr string
+	if hasCaller {
+		callerStr = f.formatCaller(entry.Caller)
+	}
+
+	if hasCaller && f.CallerFirst {
+		buf.WriteByte(' ')
+		buf.WriteString(f.colorScheme.Func(callerStr))
+	}
 
 	var fstr string
 
 	// Prefix
 	if v, ok := entry.Data[f.PrefixFieldName]; ok {
-		fstr = fmt.Sprintf("%v", v)
+		fstr = stringifyValue(v)
 	} else {
 		fstr = f.PrefixFieldName + "<missing>"
 	}
 	flen := len(fstr)
 
-	fmt.Fprint(buf, " ", f.colorScheme.Prefix(fstr))
+	buf.WriteByte(' ')
+	buf.WriteString(f.colorScheme.Prefix(fstr))
 
+	pad := 1
 	if flen < f.PrefixFieldWidth {
-		fmt.Fprint(buf, strings.Repeat(" ", int(f.PrefixFieldWidth-flen)+1))
-	} else {
-		fmt.Fprint(buf, " ")
+		pad = f.PrefixFieldWidth - flen + 1
+	}
+	for i := 0; i < pad; i++ {
+		buf.WriteByte(' ')
 	}
 
 	// Func
 	if v, ok := entry.Data[f.FuncFieldName]; ok {
-		fmt.Fprint(buf, " ", f.colorScheme.Func(fmt.Sprintf("%v", v)))
+		buf.WriteByte(' ')
+		buf.WriteString(f.colorScheme.Func(stringifyValue(v)))
 	}
 
 	// Message
-	fmt.Fprint(buf, " ", levelColor(entry.Message))
+	buf.WriteByte(' ')
+	buf.WriteString(levelColor(entry.Message))
 
-	var errpresent bool
+	tailOpened := false
 	if v, ok := entry.Data[logrus.ErrorKey]; ok {
-		errpresent = true
-		printField(buf, logrus.ErrorKey, v, f.colorScheme.Func, levelColor, true)
+		f.printField(buf, logrus.ErrorKey, v, f.colorScheme.Func, levelColor, !tailOpened)
+		tailOpened = true
 	}
 
-	keys := make([]string, 0, len(entry.Data))
+	if hasCaller && !f.CallerFirst {
+		if tailOpened {
+			buf.WriteByte(' ')
+		} else {
+			buf.WriteString(" (")
+			tailOpened = true
+		}
+		buf.WriteString(f.colorScheme.Func(callerStr))
+	}
+
+	remaining := make(map[string]struct{}, len(entry.Data))
 	for k := range entry.Data {
 		switch k {
 		case f.PrefixFieldName, f.FuncFieldName, logrus.ErrorKey:
 			continue
+		case f.TraceFieldName:
+			// Only drop it from the tail if it actually rendered as the
+			// trace column above; otherwise it's just an ordinary field.
+			if traceIsTag {
+				continue
+			}
+			remaining[k] = struct{}{}
 		default:
+			remaining[k] = struct{}{}
+		}
+	}
+
+	keys := make([]string, 0, len(remaining))
+	for _, k := range f.FieldsOrder {
+		if _, ok := remaining[k]; ok {
 			keys = append(keys, k)
+			delete(remaining, k)
 		}
 	}
-	sort.Strings(keys)
 
-	for n, k := range keys {
+	rest := make([]string, 0, len(remaining))
+	for k := range remaining {
+		rest = append(rest, k)
+	}
+	sort.Strings(rest)
+	keys = append(keys, rest...)
+
+	for _, k := range keys {
 		v := entry.Data[k]
-		printField(buf, k, v, f.colorScheme.Func, levelColor, n == 0 && !errpresent)
+		f.printField(buf, k, v, f.colorScheme.Func, levelColor, !tailOpened)
+		tailOpened = true
+	}
+	if tailOpened {
+		buf.WriteByte(')')
 	}
-	if errpresent || len(keys) > 0 {
-		fmt.Fprint(buf, ")")
+	buf.WriteByte('\n')
+
+	out := buf.Bytes()
+	if pooled {
+		cp := make([]byte, len(out))
+		copy(cp, out)
+		bufferPool.Put(buf)
+		out = cp
 	}
-	fmt.Fprint(buf, "\n")
 
-	return buf.Bytes(), nil
+	return out, nil
+}
+
+// needsQuoting reports whether s must be quoted to survive being split back
+// into tokens: it contains whitespace, '=', '"' or a non-printable rune.
+func needsQuoting(s string) bool {
+	for _, r := range s {
+		if unicode.IsSpace(r) || r == '=' || r == '"' || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// quoteValue quotes s with strconv.AppendQuote whenever it contains
+// whitespace, '=', '"' or a non-printable rune (or when forced/empty per the
+// Force/DisableQuote and QuoteEmptyFields options). Quoting happens before
+// coloring, so callers that color the result (see printField) wrap the
+// quote characters along with the value.
+func (f *Instance) quoteValue(s string) string {
+	switch {
+	case f.DisableQuote:
+		return s
+	case f.ForceQuote:
+		return string(strconv.AppendQuote(nil, s))
+	case len(s) == 0:
+		if f.QuoteEmptyFields {
+			return string(strconv.AppendQuote(nil, s))
+		}
+		return s
+	case needsQuoting(s):
+		return string(strconv.AppendQuote(nil, s))
+	default:
+		return s
+	}
 }
 
-func printField(w io.Writer, key string, val interface{}, kcolor, vcolor colorFunc, first bool) {
+func (f *Instance) printField(buf *bytes.Buffer, key string, val interface{}, kcolor, vcolor colorFunc, first bool) {
 	if first {
-		fmt.Fprint(w, " (")
+		buf.WriteString(" (")
 	} else {
-		fmt.Fprint(w, " ")
+		buf.WriteByte(' ')
 	}
+	buf.WriteString(kcolor(key))
+	buf.WriteByte('=')
 	switch v := val.(type) {
 	case fmt.Stringer:
-		fmt.Fprintf(w, "%s=%s", kcolor(key), vcolor(v.String()))
+		buf.WriteString(vcolor(f.quoteValue(v.String())))
 	case error:
-		fmt.Fprintf(w, "%s={%s}", kcolor(key), vcolor(v.Error()))
+		buf.WriteByte('{')
+		buf.WriteString(vcolor(f.quoteValue(v.Error())))
+		buf.WriteByte('}')
 	default:
-		fmt.Fprintf(w, "%s=%s", kcolor(key), vcolor(fmt.Sprintf("%#v", v)))
+		buf.WriteString(vcolor(f.quoteValue(stringifyValue(v))))
 	}
 }
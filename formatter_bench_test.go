@@ -0,0 +1,77 @@
+package textformatter_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/albenik/logrus-text-formatter"
+	"github.com/sirupsen/logrus"
+)
+
+func manyFieldsEntry() *logrus.Entry {
+	return &logrus.Entry{
+		Level:   logrus.InfoLevel,
+		Time:    time.Now(),
+		Message: "benchmark message",
+		Data: logrus.Fields{
+			"__p":           "ppp",
+			"__f":           "fff",
+			logrus.ErrorKey: errors.New("boom"),
+			"request_id":    "abc123",
+			"user_id":       42,
+			"duration":      12.345,
+			"path":          "/v1/resource",
+			"status":        200,
+		},
+	}
+}
+
+func simpleEntry() *logrus.Entry {
+	return &logrus.Entry{
+		Level:   logrus.InfoLevel,
+		Time:    time.Now(),
+		Message: "benchmark message",
+	}
+}
+
+func BenchmarkFormat_Simple(b *testing.B) {
+	f := &textformatter.Instance{DisableColors: true}
+	entry := simpleEntry()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := f.Format(entry); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFormat_ManyFields(b *testing.B) {
+	f := &textformatter.Instance{DisableColors: true}
+	entry := manyFieldsEntry()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := f.Format(entry); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFormat_ManyFields_Parallel(b *testing.B) {
+	f := &textformatter.Instance{DisableColors: true}
+	entry := manyFieldsEntry()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := f.Format(entry); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
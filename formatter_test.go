@@ -3,6 +3,7 @@ package textformatter_test
 import (
 	"bytes"
 	"fmt"
+	"runtime"
 	"testing"
 	"time"
 
@@ -13,7 +14,7 @@ import (
 
 func TestInstance_Format(t *testing.T) {
 	now := time.Now()
-	f := &textformatter.Instance{ForceFormatting: true, FullTimestamp: true, DisableColors: true}
+	f := &textformatter.Instance{FullTimestamp: true, DisableColors: true}
 	t.Run("Simple", func(t *testing.T) {
 		out := bytes.NewBuffer(nil)
 		entry := &logrus.Entry{
@@ -25,9 +26,11 @@ func TestInstance_Format(t *testing.T) {
 		}
 		s, err := f.Format(entry)
 		assert.NoError(t, err)
-		assert.Equal(t, fmt.Sprintf("%s DEBUG __p<missing>: TeSt\n", now.Format(time.RFC3339Nano)), string(s))
+		assert.Equal(t, fmt.Sprintf("%s DEBUG __p<missing>  TeSt\n", now.Format(time.RFC3339Nano)), string(s))
 	})
 	t.Run("Only __t", func(t *testing.T) {
+		// "__t" isn't an optag.Tag here, so it falls back to the field tail
+		// instead of rendering as a trace column.
 		out := bytes.NewBuffer(nil)
 		entry := &logrus.Entry{
 			Logger:  nil,
@@ -39,7 +42,7 @@ func TestInstance_Format(t *testing.T) {
 		}
 		s, err := f.Format(entry)
 		assert.NoError(t, err)
-		assert.Equal(t, fmt.Sprintf("%s DEBUG :12345: __p<missing>: TeSt\n", now.Format(time.RFC3339Nano)), string(s))
+		assert.Equal(t, fmt.Sprintf("%s DEBUG __p<missing>  TeSt (__t=12345)\n", now.Format(time.RFC3339Nano)), string(s))
 	})
 	t.Run("Only __p", func(t *testing.T) {
 		out := bytes.NewBuffer(nil)
@@ -53,7 +56,7 @@ func TestInstance_Format(t *testing.T) {
 		}
 		s, err := f.Format(entry)
 		assert.NoError(t, err)
-		assert.Equal(t, fmt.Sprintf("%s DEBUG ppp: TeSt\n", now.Format(time.RFC3339Nano)), string(s))
+		assert.Equal(t, fmt.Sprintf("%s DEBUG ppp  TeSt\n", now.Format(time.RFC3339Nano)), string(s))
 	})
 	t.Run("Only __f", func(t *testing.T) {
 		out := bytes.NewBuffer(nil)
@@ -67,7 +70,7 @@ func TestInstance_Format(t *testing.T) {
 		}
 		s, err := f.Format(entry)
 		assert.NoError(t, err)
-		assert.Equal(t, fmt.Sprintf("%s DEBUG __p<missing>.fff: TeSt\n", now.Format(time.RFC3339Nano)), string(s))
+		assert.Equal(t, fmt.Sprintf("%s DEBUG __p<missing>  fff TeSt\n", now.Format(time.RFC3339Nano)), string(s))
 	})
 	t.Run("Combined __t & __p", func(t *testing.T) {
 		out := bytes.NewBuffer(nil)
@@ -81,7 +84,7 @@ func TestInstance_Format(t *testing.T) {
 		}
 		s, err := f.Format(entry)
 		assert.NoError(t, err)
-		assert.Equal(t, fmt.Sprintf("%s DEBUG :12345: ppp: TeSt\n", now.Format(time.RFC3339Nano)), string(s))
+		assert.Equal(t, fmt.Sprintf("%s DEBUG ppp  TeSt (__t=12345)\n", now.Format(time.RFC3339Nano)), string(s))
 	})
 	t.Run("Combined __t & __f", func(t *testing.T) {
 		out := bytes.NewBuffer(nil)
@@ -95,7 +98,7 @@ func TestInstance_Format(t *testing.T) {
 		}
 		s, err := f.Format(entry)
 		assert.NoError(t, err)
-		assert.Equal(t, fmt.Sprintf("%s DEBUG :12345: __p<missing>.fff: TeSt\n", now.Format(time.RFC3339Nano)), string(s))
+		assert.Equal(t, fmt.Sprintf("%s DEBUG __p<missing>  fff TeSt (__t=12345)\n", now.Format(time.RFC3339Nano)), string(s))
 	})
 	t.Run("Combined __p & __f", func(t *testing.T) {
 		out := bytes.NewBuffer(nil)
@@ -109,7 +112,7 @@ func TestInstance_Format(t *testing.T) {
 		}
 		s, err := f.Format(entry)
 		assert.NoError(t, err)
-		assert.Equal(t, fmt.Sprintf("%s DEBUG ppp.fff: TeSt\n", now.Format(time.RFC3339Nano)), string(s))
+		assert.Equal(t, fmt.Sprintf("%s DEBUG ppp  fff TeSt\n", now.Format(time.RFC3339Nano)), string(s))
 	})
 	t.Run("Combined __t & __p & __f", func(t *testing.T) {
 		out := bytes.NewBuffer(nil)
@@ -123,6 +126,171 @@ func TestInstance_Format(t *testing.T) {
 		}
 		s, err := f.Format(entry)
 		assert.NoError(t, err)
-		assert.Equal(t, fmt.Sprintf("%s DEBUG :12345: ppp.fff: TeSt\n", now.Format(time.RFC3339Nano)), string(s))
+		assert.Equal(t, fmt.Sprintf("%s DEBUG ppp  fff TeSt (__t=12345)\n", now.Format(time.RFC3339Nano)), string(s))
+	})
+}
+
+func TestInstance_Format_FieldsOrder(t *testing.T) {
+	f := &textformatter.Instance{DisableTimestamp: true, DisableColors: true, FieldsOrder: []string{"b", "a"}}
+	entry := &logrus.Entry{
+		Buffer:  bytes.NewBuffer(nil),
+		Level:   logrus.InfoLevel,
+		Message: "msg",
+		Data:    logrus.Fields{"a": "1", "b": "2", "c": "3"},
+	}
+
+	s, err := f.Format(entry)
+	assert.NoError(t, err)
+	assert.Equal(t, " INFO __p<missing>  msg (b=2 a=1 c=3)\n", string(s))
+}
+
+func TestInstance_Format_Quoting(t *testing.T) {
+	f := &textformatter.Instance{DisableTimestamp: true, DisableColors: true}
+	entry := &logrus.Entry{
+		Buffer:  bytes.NewBuffer(nil),
+		Level:   logrus.InfoLevel,
+		Message: "msg",
+		Data:    logrus.Fields{"plain": "value", "spaced": "has space"},
+	}
+
+	s, err := f.Format(entry)
+	assert.NoError(t, err)
+	assert.Equal(t, " INFO __p<missing>  msg (plain=value spaced=\"has space\")\n", string(s))
+}
+
+func TestInstance_Format_ForceQuote(t *testing.T) {
+	f := &textformatter.Instance{DisableTimestamp: true, DisableColors: true, ForceQuote: true}
+	entry := &logrus.Entry{
+		Buffer:  bytes.NewBuffer(nil),
+		Level:   logrus.InfoLevel,
+		Message: "msg",
+		Data:    logrus.Fields{"plain": "value"},
+	}
+
+	s, err := f.Format(entry)
+	assert.NoError(t, err)
+	assert.Equal(t, " INFO __p<missing>  msg (plain=\"value\")\n", string(s))
+}
+
+func TestInstance_Format_QuoteEmptyFields(t *testing.T) {
+	f := &textformatter.Instance{DisableTimestamp: true, DisableColors: true, QuoteEmptyFields: true}
+	entry := &logrus.Entry{
+		Buffer:  bytes.NewBuffer(nil),
+		Level:   logrus.InfoLevel,
+		Message: "msg",
+		Data:    logrus.Fields{"empty": ""},
+	}
+
+	s, err := f.Format(entry)
+	assert.NoError(t, err)
+	assert.Equal(t, " INFO __p<missing>  msg (empty=\"\")\n", string(s))
+}
+
+func TestInstance_Format_DisableQuote(t *testing.T) {
+	f := &textformatter.Instance{DisableTimestamp: true, DisableColors: true, DisableQuote: true}
+	entry := &logrus.Entry{
+		Buffer:  bytes.NewBuffer(nil),
+		Level:   logrus.InfoLevel,
+		Message: "msg",
+		Data:    logrus.Fields{"spaced": "has space"},
+	}
+
+	s, err := f.Format(entry)
+	assert.NoError(t, err)
+	assert.Equal(t, " INFO __p<missing>  msg (spaced=has space)\n", string(s))
+}
+
+func callerEntry(f *textformatter.Instance) *logrus.Entry {
+	logger := logrus.New()
+	logger.ReportCaller = true
+	return &logrus.Entry{
+		Logger:  logger,
+		Buffer:  bytes.NewBuffer(nil),
+		Level:   logrus.InfoLevel,
+		Message: "msg",
+		Caller:  &runtime.Frame{File: "main.go", Line: 42, Function: "main.run"},
+	}
+}
+
+func TestInstance_Format_Caller(t *testing.T) {
+	f := &textformatter.Instance{DisableTimestamp: true, DisableColors: true}
+	entry := callerEntry(f)
+
+	s, err := f.Format(entry)
+	assert.NoError(t, err)
+	assert.Equal(t, " INFO __p<missing>  msg (main.go:42#main.run)\n", string(s))
+}
+
+func TestInstance_Format_CallerFirst(t *testing.T) {
+	f := &textformatter.Instance{DisableTimestamp: true, DisableColors: true, CallerFirst: true}
+	entry := callerEntry(f)
+
+	s, err := f.Format(entry)
+	assert.NoError(t, err)
+	assert.Equal(t, " INFO main.go:42#main.run __p<missing>  msg\n", string(s))
+}
+
+func TestInstance_Format_DisableCaller(t *testing.T) {
+	f := &textformatter.Instance{DisableTimestamp: true, DisableColors: true, DisableCaller: true}
+	entry := callerEntry(f)
+
+	s, err := f.Format(entry)
+	assert.NoError(t, err)
+	assert.Equal(t, " INFO __p<missing>  msg\n", string(s))
+}
+
+func TestInstance_Format_CustomCallerFormatter(t *testing.T) {
+	f := &textformatter.Instance{
+		DisableTimestamp: true,
+		DisableColors:    true,
+		CustomCallerFormatter: func(frame *runtime.Frame) string {
+			return fmt.Sprintf("<%s>", frame.Function)
+		},
+	}
+	entry := callerEntry(f)
+
+	s, err := f.Format(entry)
+	assert.NoError(t, err)
+	assert.Equal(t, " INFO __p<missing>  msg (<main.run>)\n", string(s))
+}
+
+type fakeTag struct {
+	s string
+}
+
+func (t fakeTag) String() string  { return t.s }
+func (t fakeTag) Time() time.Time { return time.Time{} }
+
+func TestInstance_Format_Trace(t *testing.T) {
+	f := &textformatter.Instance{DisableTimestamp: true, DisableColors: true}
+	entry := &logrus.Entry{
+		Buffer:  bytes.NewBuffer(nil),
+		Level:   logrus.InfoLevel,
+		Message: "msg",
+		Data:    logrus.Fields{"__t": fakeTag{s: "root 5"}},
+	}
+
+	s, err := f.Format(entry)
+	assert.NoError(t, err)
+	assert.Equal(t, " INFO root 5 __p<missing>  msg\n", string(s))
+}
+
+func TestInstance_EnvironmentOverrideColors(t *testing.T) {
+	t.Run("NO_COLOR disables colors", func(t *testing.T) {
+		t.Setenv("NO_COLOR", "1")
+		f := &textformatter.Instance{EnvironmentOverrideColors: true, ForceColors: true}
+		entry := &logrus.Entry{Buffer: bytes.NewBuffer(nil), Level: logrus.InfoLevel, Message: "msg", Data: logrus.Fields{}}
+		s, err := f.Format(entry)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(s), "\x1b[")
+	})
+
+	t.Run("CLICOLOR_FORCE forces colors", func(t *testing.T) {
+		t.Setenv("CLICOLOR_FORCE", "1")
+		f := &textformatter.Instance{EnvironmentOverrideColors: true}
+		entry := &logrus.Entry{Buffer: bytes.NewBuffer(nil), Level: logrus.InfoLevel, Message: "msg", Data: logrus.Fields{}}
+		s, err := f.Format(entry)
+		assert.NoError(t, err)
+		assert.Contains(t, string(s), "\x1b[")
 	})
 }
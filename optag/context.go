@@ -0,0 +1,51 @@
+package optag
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+type ctxKey struct{}
+
+// WithContext returns a copy of ctx carrying tag as its current span tag.
+func WithContext(ctx context.Context, tag Tag) context.Context {
+	return context.WithValue(ctx, ctxKey{}, tag)
+}
+
+// FromContext returns the tag previously attached to ctx via WithContext, if
+// any.
+func FromContext(ctx context.Context) (Tag, bool) {
+	tag, ok := ctx.Value(ctxKey{}).(Tag)
+	return tag, ok
+}
+
+// Hook injects the Tag carried by an entry's context (as attached via
+// WithContext) into the entry's data under FieldName, so callers get
+// span-like correlation without threading a field through every log call.
+type Hook struct {
+	// FieldName is the entry.Data key the tag is stored under. Defaults to
+	// "__t" to match Instance.TraceFieldName.
+	FieldName string
+}
+
+func (h *Hook) fieldName() string {
+	if len(h.FieldName) == 0 {
+		return "__t"
+	}
+	return h.FieldName
+}
+
+func (h *Hook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *Hook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	if tag, ok := FromContext(entry.Context); ok {
+		entry.Data[h.fieldName()] = tag
+	}
+	return nil
+}
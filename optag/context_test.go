@@ -0,0 +1,56 @@
+package optag_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/albenik/logrus-text-formatter/optag"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithContext_FromContext(t *testing.T) {
+	tag := optag.New(nil)
+	ctx := optag.WithContext(context.Background(), tag)
+
+	got, ok := optag.FromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, tag, got)
+}
+
+func TestFromContext_Absent(t *testing.T) {
+	_, ok := optag.FromContext(context.Background())
+	assert.False(t, ok)
+}
+
+func TestHook_Fire(t *testing.T) {
+	tag := optag.New(nil)
+	entry := &logrus.Entry{
+		Context: optag.WithContext(context.Background(), tag),
+		Data:    logrus.Fields{},
+	}
+
+	hook := &optag.Hook{}
+	assert.NoError(t, hook.Fire(entry))
+	assert.Equal(t, tag, entry.Data["__t"])
+}
+
+func TestHook_Fire_CustomFieldName(t *testing.T) {
+	tag := optag.New(nil)
+	entry := &logrus.Entry{
+		Context: optag.WithContext(context.Background(), tag),
+		Data:    logrus.Fields{},
+	}
+
+	hook := &optag.Hook{FieldName: "span"}
+	assert.NoError(t, hook.Fire(entry))
+	assert.Equal(t, tag, entry.Data["span"])
+}
+
+func TestHook_Fire_NoContext(t *testing.T) {
+	entry := &logrus.Entry{Data: logrus.Fields{}}
+
+	hook := &optag.Hook{}
+	assert.NoError(t, hook.Fire(entry))
+	assert.Empty(t, entry.Data)
+}
@@ -0,0 +1,132 @@
+package textformatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LogfmtInstance renders entries as single-line `key=value` logfmt records,
+// reusing the field pipeline (prefix/func/trace field names, timestamp
+// format, FieldsOrder) configured on the embedded Instance instead of the
+// colored human-readable layout.
+type LogfmtInstance struct {
+	Instance
+
+	// TraceKey, PrefixKey and FuncKey name the top-level keys the reserved
+	// __t/__p/__f fields are surfaced under. Default to "trace", "prefix"
+	// and "func".
+	TraceKey  string
+	PrefixKey string
+	FuncKey   string
+}
+
+func (f *LogfmtInstance) Format(entry *logrus.Entry) ([]byte, error) {
+	trace, prefix, fn := reservedKeys(f.TraceKey, f.PrefixKey, f.FuncKey)
+	rec := f.Instance.buildRecord(entry, trace, prefix, fn)
+
+	var buf *bytes.Buffer
+	if entry.Buffer != nil {
+		buf = entry.Buffer
+	} else {
+		buf = &bytes.Buffer{}
+	}
+
+	for i, e := range rec {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(e.Key)
+		buf.WriteByte('=')
+		buf.WriteString(f.Instance.quoteValue(stringifyValue(e.Value)))
+	}
+	buf.WriteByte('\n')
+
+	return buf.Bytes(), nil
+}
+
+// JSONInstance renders entries as single-line JSON objects, reusing the same
+// field pipeline as LogfmtInstance.
+type JSONInstance struct {
+	Instance
+
+	// TraceKey, PrefixKey and FuncKey name the top-level keys the reserved
+	// __t/__p/__f fields are surfaced under. Default to "trace", "prefix"
+	// and "func".
+	TraceKey  string
+	PrefixKey string
+	FuncKey   string
+}
+
+func (f *JSONInstance) Format(entry *logrus.Entry) ([]byte, error) {
+	trace, prefix, fn := reservedKeys(f.TraceKey, f.PrefixKey, f.FuncKey)
+	rec := f.Instance.buildRecord(entry, trace, prefix, fn)
+
+	data := make(map[string]interface{}, len(rec))
+	for _, e := range rec {
+		data[e.Key] = jsonifyValue(e.Value)
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("textformatter: marshal json fields: %w", err)
+	}
+
+	var buf *bytes.Buffer
+	if entry.Buffer != nil {
+		buf = entry.Buffer
+	} else {
+		buf = &bytes.Buffer{}
+	}
+	buf.Write(b)
+	buf.WriteByte('\n')
+
+	return buf.Bytes(), nil
+}
+
+// reservedKeys fills in the default top-level keys ("trace", "prefix",
+// "func") for whichever of trace/prefix/fn overrides were left empty. Shared
+// by LogfmtInstance and JSONInstance.
+func reservedKeys(trace, prefix, fn string) (string, string, string) {
+	if len(trace) == 0 {
+		trace = "trace"
+	}
+	if len(prefix) == 0 {
+		prefix = "prefix"
+	}
+	if len(fn) == 0 {
+		fn = "func"
+	}
+	return trace, prefix, fn
+}
+
+// stringifyValue renders val as plain text: String() for a fmt.Stringer,
+// Error() for an error, %v for anything else. Unlike printField it adds no
+// coloring, no quoting and no braces around error text.
+func stringifyValue(val interface{}) string {
+	switch v := val.(type) {
+	case fmt.Stringer:
+		return v.String()
+	case error:
+		return v.Error()
+	case string:
+		return v
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// jsonifyValue unwraps Stringer/error values so they marshal as plain JSON
+// strings instead of (likely empty) objects.
+func jsonifyValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case fmt.Stringer:
+		return v.String()
+	case error:
+		return v.Error()
+	default:
+		return v
+	}
+}
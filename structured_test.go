@@ -0,0 +1,104 @@
+package textformatter_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/albenik/logrus-text-formatter"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogfmtInstance_Format(t *testing.T) {
+	f := &textformatter.LogfmtInstance{Instance: textformatter.Instance{DisableTimestamp: true}}
+	entry := &logrus.Entry{
+		Level:   logrus.InfoLevel,
+		Message: "hello",
+		Data:    logrus.Fields{"foo": "bar"},
+	}
+
+	s, err := f.Format(entry)
+	assert.NoError(t, err)
+	assert.Equal(t, "level=INFO msg=hello foo=bar\n", string(s))
+}
+
+func TestLogfmtInstance_Format_ReservedKeyCollision(t *testing.T) {
+	f := &textformatter.LogfmtInstance{Instance: textformatter.Instance{DisableTimestamp: true}}
+	entry := &logrus.Entry{
+		Level:   logrus.InfoLevel,
+		Message: "hello",
+		Data:    logrus.Fields{"level": "user-level", "msg": "user-msg", "extra": "ok"},
+	}
+
+	s, err := f.Format(entry)
+	assert.NoError(t, err)
+	assert.Equal(t, "level=INFO msg=hello extra=ok\n", string(s))
+}
+
+func TestJSONInstance_Format(t *testing.T) {
+	now := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	f := &textformatter.JSONInstance{}
+	entry := &logrus.Entry{
+		Level:   logrus.InfoLevel,
+		Time:    now,
+		Message: "hello",
+		Data:    logrus.Fields{"foo": "bar"},
+	}
+
+	s, err := f.Format(entry)
+	assert.NoError(t, err)
+
+	var got map[string]interface{}
+	assert.NoError(t, json.Unmarshal(s, &got))
+	assert.Equal(t, now.Format(time.RFC3339Nano), got["time"])
+	assert.Equal(t, "INFO", got["level"])
+	assert.Equal(t, "hello", got["msg"])
+	assert.Equal(t, "bar", got["foo"])
+}
+
+func TestJSONInstance_Format_ReservedKeyCollision(t *testing.T) {
+	// A user field literally named "time"/"level"/"msg" must not clobber the
+	// real reserved value once buildRecord's output is flattened into a map.
+	now := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	f := &textformatter.JSONInstance{}
+	entry := &logrus.Entry{
+		Level:   logrus.InfoLevel,
+		Time:    now,
+		Message: "hello",
+		Data: logrus.Fields{
+			"time":  "user-supplied",
+			"level": "user-level",
+			"msg":   "user-msg",
+			"extra": "ok",
+		},
+	}
+
+	s, err := f.Format(entry)
+	assert.NoError(t, err)
+
+	var got map[string]interface{}
+	assert.NoError(t, json.Unmarshal(s, &got))
+	assert.Equal(t, now.Format(time.RFC3339Nano), got["time"])
+	assert.Equal(t, "INFO", got["level"])
+	assert.Equal(t, "hello", got["msg"])
+	assert.Equal(t, "ok", got["extra"])
+}
+
+func TestJSONInstance_Format_CustomKeys(t *testing.T) {
+	f := &textformatter.JSONInstance{TraceKey: "span", PrefixKey: "svc", FuncKey: "fn"}
+	entry := &logrus.Entry{
+		Level:   logrus.InfoLevel,
+		Message: "hello",
+		Data:    logrus.Fields{"__t": "tag-val", "__p": "svc-name", "__f": "func-name"},
+	}
+
+	s, err := f.Format(entry)
+	assert.NoError(t, err)
+
+	var got map[string]interface{}
+	assert.NoError(t, json.Unmarshal(s, &got))
+	assert.Equal(t, "tag-val", got["span"])
+	assert.Equal(t, "svc-name", got["svc"])
+	assert.Equal(t, "func-name", got["fn"])
+}